@@ -0,0 +1,29 @@
+package conf
+
+import "time"
+
+// scannerOptions holds the scanner-related settings under the `Scanner` config section.
+type scannerOptions struct {
+	// ConcurrentLibraries bounds how many libraries are scanned at the same time. A value <= 0
+	// means "pick a sensible default based on the number of libraries and CPUs".
+	ConcurrentLibraries int
+	// ConcurrentFolders bounds how many directories of a single library are walked at the same
+	// time. A value <= 1 keeps the walk serial, as it always was before this setting existed.
+	ConcurrentFolders int
+	// WatcherEnabled turns on the fsnotify-based watcher after each library's initial full scan,
+	// so changes on disk are picked up without waiting for the next scheduled scan.
+	WatcherEnabled bool
+	// WatcherDebounce is how long a directory must be quiet before a watcher-triggered rescan of
+	// it fires, so a burst of writes to the same directory only causes one rescan.
+	WatcherDebounce time.Duration
+	// WatcherFallbackInterval is how often the watcher does a full rescan of a library as a
+	// safety net, in case an fsnotify event was missed (e.g. a watch queue overflow).
+	WatcherFallbackInterval time.Duration
+}
+
+type configOptions struct {
+	Scanner scannerOptions
+}
+
+// Server holds the active configuration, populated from the config file/environment at startup.
+var Server = &configOptions{}
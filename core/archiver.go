@@ -1,13 +1,16 @@
 package core
 
 import (
+	"archive/tar"
 	"archive/zip"
+	"compress/gzip"
 	"context"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/Masterminds/squirrel"
 	"github.com/navidrome/navidrome/log"
@@ -15,10 +18,22 @@ import (
 	"github.com/navidrome/navidrome/utils/slice"
 )
 
+// ArchiveFormat selects the on-the-wire container used by the Archiver. Zip is the default, as
+// it's the only format most clients know how to open without help, but tar/tar.gz let clients
+// that support them start extracting before the download finishes, since they don't need a
+// trailing central directory.
+type ArchiveFormat string
+
+const (
+	ArchiveFormatZip   ArchiveFormat = "zip"
+	ArchiveFormatTar   ArchiveFormat = "tar"
+	ArchiveFormatTarGz ArchiveFormat = "tar.gz"
+)
+
 type Archiver interface {
-	ZipAlbum(ctx context.Context, id string, format string, bitrate int, w io.Writer) error
-	ZipArtist(ctx context.Context, id string, format string, bitrate int, w io.Writer) error
-	ZipPlaylist(ctx context.Context, id string, format string, bitrate int, w io.Writer) error
+	ZipAlbum(ctx context.Context, id string, archiveFormat ArchiveFormat, format string, bitrate int, includePlaylist bool, w io.Writer) error
+	ZipArtist(ctx context.Context, id string, archiveFormat ArchiveFormat, format string, bitrate int, includePlaylist bool, w io.Writer) error
+	ZipPlaylist(ctx context.Context, id string, archiveFormat ArchiveFormat, format string, bitrate int, w io.Writer) error
 }
 
 func NewArchiver(ms MediaStreamer, ds model.DataStore) Archiver {
@@ -30,7 +45,7 @@ type archiver struct {
 	ms MediaStreamer
 }
 
-func (a *archiver) ZipAlbum(ctx context.Context, id string, format string, bitrate int, out io.Writer) error {
+func (a *archiver) ZipAlbum(ctx context.Context, id string, archiveFormat ArchiveFormat, format string, bitrate int, includePlaylist bool, out io.Writer) error {
 	mfs, err := a.ds.MediaFile(ctx).GetAll(model.QueryOptions{
 		Filters: squirrel.Eq{"album_id": id},
 		Sort:    "album",
@@ -39,10 +54,10 @@ func (a *archiver) ZipAlbum(ctx context.Context, id string, format string, bitra
 		log.Error(ctx, "Error loading mediafiles from album", "id", id, err)
 		return err
 	}
-	return a.zipAlbums(ctx, id, format, bitrate, out, mfs)
+	return a.archiveAlbums(ctx, id, archiveFormat, format, bitrate, includePlaylist, out, mfs)
 }
 
-func (a *archiver) ZipArtist(ctx context.Context, id string, format string, bitrate int, out io.Writer) error {
+func (a *archiver) ZipArtist(ctx context.Context, id string, archiveFormat ArchiveFormat, format string, bitrate int, includePlaylist bool, out io.Writer) error {
 	mfs, err := a.ds.MediaFile(ctx).GetAll(model.QueryOptions{
 		Filters: squirrel.Eq{"album_artist_id": id},
 		Sort:    "album",
@@ -51,11 +66,15 @@ func (a *archiver) ZipArtist(ctx context.Context, id string, format string, bitr
 		log.Error(ctx, "Error loading mediafiles from artist", "id", id, err)
 		return err
 	}
-	return a.zipAlbums(ctx, id, format, bitrate, out, mfs)
+	return a.archiveAlbums(ctx, id, archiveFormat, format, bitrate, includePlaylist, out, mfs)
 }
 
-func (a *archiver) zipAlbums(ctx context.Context, id string, format string, bitrate int, out io.Writer, mfs model.MediaFiles) error {
-	z := zip.NewWriter(out)
+func (a *archiver) archiveAlbums(ctx context.Context, id string, archiveFormat ArchiveFormat, format string, bitrate int, includePlaylist bool, out io.Writer, mfs model.MediaFiles) error {
+	w, err := newArchiveWriter(archiveFormat, out)
+	if err != nil {
+		log.Error(ctx, "Error creating archive", "id", id, "archiveFormat", archiveFormat, err)
+		return err
+	}
 	albums := slice.Group(mfs, func(mf model.MediaFile) string {
 		return mf.AlbumID
 	})
@@ -64,49 +83,80 @@ func (a *archiver) zipAlbums(ctx context.Context, id string, format string, bitr
 		isMultDisc := len(discs) > 1
 		log.Debug(ctx, "Zipping album", "name", album[0].Album, "artist", album[0].AlbumArtist,
 			"format", format, "bitrate", bitrate, "isMultDisc", isMultDisc, "numTracks", len(album))
+		filenames := make(map[string]string, len(album))
 		for _, mf := range album {
 			file := a.albumFilename(mf, format, isMultDisc)
-			_ = a.addFileToZip(ctx, z, mf, format, bitrate, file)
+			filenames[mf.ID] = file
+			_ = a.addFileToArchive(ctx, w, mf, format, bitrate, file)
+		}
+		if includePlaylist {
+			for _, disc := range discs {
+				dir := a.albumDir(disc[0], isMultDisc)
+				// The playlist sits in the same directory as the disc's tracks, so its entries are
+				// relative to that directory.
+				discFilenames := make([]string, len(disc))
+				for i, mf := range disc {
+					discFilenames[i] = strings.TrimPrefix(filenames[mf.ID], dir+"/")
+				}
+				name := fmt.Sprintf("%s/playlist.m3u8", dir)
+				if err := a.writeM3U8(ctx, w, name, disc, discFilenames); err != nil {
+					log.Warn(ctx, "Error writing album playlist", "album", disc[0].Album, err)
+				}
+			}
 		}
 	}
-	err := z.Close()
+	err = w.Close()
 	if err != nil {
-		log.Error(ctx, "Error closing zip file", "id", id, err)
+		log.Error(ctx, "Error closing archive", "id", id, err)
 	}
 	return err
 }
 
+func (a *archiver) albumDir(mf model.MediaFile, isMultDisc bool) string {
+	if isMultDisc {
+		return fmt.Sprintf("%s/Disc %02d", mf.Album, mf.DiscNumber)
+	}
+	return mf.Album
+}
+
 func (a *archiver) albumFilename(mf model.MediaFile, format string, isMultDisc bool) string {
 	_, file := filepath.Split(mf.Path)
 	if format != "raw" {
 		file = strings.TrimSuffix(file, mf.Suffix) + format
 	}
-	if isMultDisc {
-		file = fmt.Sprintf("Disc %02d/%s", mf.DiscNumber, file)
-	}
-	return fmt.Sprintf("%s/%s", mf.Album, file)
+	return fmt.Sprintf("%s/%s", a.albumDir(mf, isMultDisc), file)
 }
 
-func (a *archiver) ZipPlaylist(ctx context.Context, id string, format string, bitrate int, out io.Writer) error {
+func (a *archiver) ZipPlaylist(ctx context.Context, id string, archiveFormat ArchiveFormat, format string, bitrate int, out io.Writer) error {
 	pls, err := a.ds.Playlist(ctx).GetWithTracks(id, true)
 	if err != nil {
 		log.Error(ctx, "Error loading mediafiles from playlist", "id", id, err)
 		return err
 	}
-	return a.zipPlaylist(ctx, id, format, bitrate, out, pls)
+	return a.archivePlaylist(ctx, id, archiveFormat, format, bitrate, out, pls)
 }
 
-func (a *archiver) zipPlaylist(ctx context.Context, id string, format string, bitrate int, out io.Writer, pls *model.Playlist) error {
+func (a *archiver) archivePlaylist(ctx context.Context, id string, archiveFormat ArchiveFormat, format string, bitrate int, out io.Writer, pls *model.Playlist) error {
 	mfs := pls.MediaFiles()
-	z := zip.NewWriter(out)
+	w, err := newArchiveWriter(archiveFormat, out)
+	if err != nil {
+		log.Error(ctx, "Error creating archive", "id", id, "archiveFormat", archiveFormat, err)
+		return err
+	}
 	log.Debug(ctx, "Zipping playlist", "name", pls.Name, "format", format, "bitrate", bitrate, "numTracks", len(mfs))
+	filenames := make([]string, len(mfs))
+	for idx, mf := range mfs {
+		filenames[idx] = a.playlistFilename(mf, format, idx)
+	}
+	if err := a.writeM3U8(ctx, w, pls.Name+".m3u8", mfs, filenames); err != nil {
+		log.Warn(ctx, "Error writing playlist m3u8", "name", pls.Name, err)
+	}
 	for idx, mf := range mfs {
-		file := a.playlistFilename(mf, format, idx)
-		_ = a.addFileToZip(ctx, z, mf, format, bitrate, file)
+		_ = a.addFileToArchive(ctx, w, mf, format, bitrate, filenames[idx])
 	}
-	err := z.Close()
+	err = w.Close()
 	if err != nil {
-		log.Error(ctx, "Error closing zip file", "id", id, err)
+		log.Error(ctx, "Error closing archive", "id", id, err)
 	}
 	return err
 }
@@ -120,25 +170,43 @@ func (a *archiver) playlistFilename(mf model.MediaFile, format string, idx int)
 	return file
 }
 
-func (a *archiver) addFileToZip(ctx context.Context, z *zip.Writer, mf model.MediaFile, format string, bitrate int, filename string) error {
-	w, err := z.CreateHeader(&zip.FileHeader{
-		Name:     filename,
-		Modified: mf.UpdatedAt,
-		Method:   zip.Store,
-	})
+// writeM3U8 adds an EXTM3U playlist listing tracks in order, referencing the filenames each
+// track was (or will be) archived under, so extracting the archive keeps the playlist order.
+func (a *archiver) writeM3U8(ctx context.Context, w archiveWriter, name string, tracks model.MediaFiles, filenames []string) error {
+	var sb strings.Builder
+	sb.WriteString("#EXTM3U\n")
+	for i, mf := range tracks {
+		fmt.Fprintf(&sb, "#EXTINF:%.0f,%s - %s\n", mf.Duration, mf.Artist, mf.Title)
+		sb.WriteString(filenames[i])
+		sb.WriteString("\n")
+	}
+	content := sb.String()
+	entry, err := w.createEntry(name, time.Now(), int64(len(content)))
 	if err != nil {
-		log.Error(ctx, "Error creating zip entry", "file", mf.Path, err)
+		log.Error(ctx, "Error creating m3u8 entry", "name", name, err)
 		return err
 	}
+	_, err = io.WriteString(entry, content)
+	return err
+}
 
+func (a *archiver) addFileToArchive(ctx context.Context, w archiveWriter, mf model.MediaFile, format string, bitrate int, filename string) error {
 	var r io.ReadCloser
+	var size int64
+	var err error
 	if format != "raw" {
 		r, err = a.ms.DoStream(ctx, &mf, format, bitrate)
+		if err == nil && w.requiresSize() {
+			// Formats like tar need the entry size before any of its content is written, but a
+			// transcoding stream doesn't know its own size upfront, so spool it to disk first.
+			r, size, err = spoolToTempFile(r)
+		}
 	} else {
 		r, err = os.Open(mf.Path)
+		size = mf.Size
 	}
 	if err != nil {
-		log.Error(ctx, "Error opening file for zipping", "file", mf.Path, "format", format, err)
+		log.Error(ctx, "Error opening file for archiving", "file", mf.Path, "format", format, err)
 		return err
 	}
 
@@ -148,11 +216,133 @@ func (a *archiver) addFileToZip(ctx context.Context, z *zip.Writer, mf model.Med
 		}
 	}()
 
-	_, err = io.Copy(w, r)
+	entry, err := w.createEntry(filename, mf.UpdatedAt, size)
+	if err != nil {
+		log.Error(ctx, "Error creating archive entry", "file", mf.Path, err)
+		return err
+	}
+
+	_, err = io.Copy(entry, r)
 	if err != nil {
-		log.Error(ctx, "Error zipping file", "file", mf.Path, err)
+		log.Error(ctx, "Error copying file to archive", "file", mf.Path, err)
 		return err
 	}
 
 	return nil
 }
+
+// spooledFile is a temp file that deletes itself on Close, so callers can treat it like any
+// other io.ReadCloser without remembering to clean up.
+type spooledFile struct {
+	*os.File
+}
+
+func (f *spooledFile) Close() error {
+	err := f.File.Close()
+	_ = os.Remove(f.File.Name())
+	return err
+}
+
+// spoolToTempFile copies r to a temp file and rewinds it, returning its exact size along the way.
+// The caller owns the returned ReadCloser and must Close it once done.
+func spoolToTempFile(r io.ReadCloser) (io.ReadCloser, int64, error) {
+	defer r.Close()
+	tmp, err := os.CreateTemp("", "navidrome-archive-*")
+	if err != nil {
+		return nil, 0, err
+	}
+	spooled := &spooledFile{tmp}
+	size, err := io.Copy(tmp, r)
+	if err != nil {
+		_ = spooled.Close()
+		return nil, 0, err
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		_ = spooled.Close()
+		return nil, 0, err
+	}
+	return spooled, size, nil
+}
+
+// archiveWriter abstracts over the on-disk container format (zip, tar, tar.gz), so the
+// track-enumeration logic above doesn't need to know which one it is writing to.
+type archiveWriter interface {
+	// createEntry starts a new entry named name inside the archive. size is the number of bytes
+	// that will be written to the returned io.Writer; it is ignored by formats, like zip, that
+	// don't need it ahead of time.
+	createEntry(name string, modTime time.Time, size int64) (io.Writer, error)
+	// requiresSize reports whether createEntry needs an accurate size before any content is
+	// written, forcing the caller to spool sources whose length isn't known upfront.
+	requiresSize() bool
+	Close() error
+}
+
+func newArchiveWriter(format ArchiveFormat, out io.Writer) (archiveWriter, error) {
+	switch format {
+	case "", ArchiveFormatZip:
+		return &zipArchiveWriter{z: zip.NewWriter(out)}, nil
+	case ArchiveFormatTar:
+		return &tarArchiveWriter{tw: tar.NewWriter(out)}, nil
+	case ArchiveFormatTarGz:
+		gz := gzip.NewWriter(out)
+		return &tarArchiveWriter{tw: tar.NewWriter(gz), gz: gz}, nil
+	default:
+		return nil, fmt.Errorf("unsupported archive format: %s", format)
+	}
+}
+
+type zipArchiveWriter struct {
+	z *zip.Writer
+}
+
+func (w *zipArchiveWriter) createEntry(name string, modTime time.Time, _ int64) (io.Writer, error) {
+	return w.z.CreateHeader(&zip.FileHeader{
+		Name:     name,
+		Modified: modTime,
+		Method:   zip.Store,
+	})
+}
+
+func (w *zipArchiveWriter) requiresSize() bool {
+	return false
+}
+
+func (w *zipArchiveWriter) Close() error {
+	return w.z.Close()
+}
+
+// tarArchiveWriter writes entries to a tar stream, optionally gzip-compressed. Unlike zip, tar
+// requires the entry size to be known before any of its content is written, so callers that
+// can't size their content upfront (e.g. a transcoding stream) must spool it first - see
+// requiresSize and spoolToTempFile.
+type tarArchiveWriter struct {
+	tw *tar.Writer
+	gz *gzip.Writer
+}
+
+func (w *tarArchiveWriter) createEntry(name string, modTime time.Time, size int64) (io.Writer, error) {
+	err := w.tw.WriteHeader(&tar.Header{
+		Name:    name,
+		Mode:    0o644,
+		Size:    size,
+		ModTime: modTime,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return w.tw, nil
+}
+
+func (w *tarArchiveWriter) requiresSize() bool {
+	return true
+}
+
+func (w *tarArchiveWriter) Close() error {
+	if err := w.tw.Close(); err != nil {
+		return err
+	}
+	if w.gz != nil {
+		return w.gz.Close()
+	}
+	return nil
+}
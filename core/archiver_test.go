@@ -0,0 +1,159 @@
+package core
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/navidrome/navidrome/model"
+)
+
+func TestNewArchiveWriter_Zip(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := newArchiveWriter(ArchiveFormatZip, &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w.requiresSize() {
+		t.Error("zip writer should not require the entry size upfront")
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestNewArchiveWriter_Tar(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := newArchiveWriter(ArchiveFormatTar, &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !w.requiresSize() {
+		t.Error("tar writer should require the entry size upfront")
+	}
+
+	entry, err := w.createEntry("track.flac", time.Unix(0, 0), int64(len("hello")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.WriteString(entry, "hello"); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	tr := tar.NewReader(&buf)
+	hdr, err := tr.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hdr.Name != "track.flac" {
+		t.Errorf("entry name = %q, want track.flac", hdr.Name)
+	}
+	if hdr.Size != int64(len("hello")) {
+		t.Errorf("entry size = %d, want %d", hdr.Size, len("hello"))
+	}
+	content, err := io.ReadAll(tr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != "hello" {
+		t.Errorf("entry content = %q, want hello", content)
+	}
+}
+
+func TestNewArchiveWriter_TarGz(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := newArchiveWriter(ArchiveFormatTarGz, &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry, err := w.createEntry("track.flac", time.Unix(0, 0), int64(len("hi")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.WriteString(entry, "hi"); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	gz, err := gzip.NewReader(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer gz.Close()
+	tr := tar.NewReader(gz)
+	hdr, err := tr.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hdr.Name != "track.flac" {
+		t.Errorf("entry name = %q, want track.flac", hdr.Name)
+	}
+}
+
+func TestNewArchiveWriter_UnsupportedFormat(t *testing.T) {
+	var buf bytes.Buffer
+	_, err := newArchiveWriter(ArchiveFormat("rar"), &buf)
+	if err == nil {
+		t.Error("expected an error for an unsupported archive format")
+	}
+}
+
+func TestWriteM3U8(t *testing.T) {
+	tracks := model.MediaFiles{
+		{ID: "1", Artist: "Artist A", Title: "Track One", Duration: 125.4},
+		{ID: "2", Artist: "Artist A", Title: "Track Two", Duration: 200},
+	}
+	filenames := []string{"01 - Artist A - Track One.mp3", "02 - Artist A - Track Two.mp3"}
+
+	var buf bytes.Buffer
+	w, err := newArchiveWriter(ArchiveFormatZip, &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := &archiver{}
+	if err := a.writeM3U8(context.Background(), w, "playlist.m3u8", tracks, filenames); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(zr.File) != 1 || zr.File[0].Name != "playlist.m3u8" {
+		t.Fatalf("expected a single playlist.m3u8 entry, got %v", zr.File)
+	}
+	rc, err := zr.File[0].Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+	content, err := io.ReadAll(rc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := string(content)
+	if !strings.HasPrefix(got, "#EXTM3U\n") {
+		t.Errorf("m3u8 should start with #EXTM3U, got %q", got)
+	}
+	if !strings.Contains(got, "#EXTINF:125,Artist A - Track One\n"+filenames[0]) {
+		t.Errorf("m3u8 missing expected entry for track one, got %q", got)
+	}
+	if !strings.Contains(got, "#EXTINF:200,Artist A - Track Two\n"+filenames[1]) {
+		t.Errorf("m3u8 missing expected entry for track two, got %q", got)
+	}
+}
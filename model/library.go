@@ -0,0 +1,13 @@
+package model
+
+// Library represents a top-level music folder configured by the admin. Each Library is scanned
+// independently and owns its own set of folders, artists and albums.
+type Library struct {
+	ID   int
+	Name string
+	Path string
+	// ExcludePatterns lists .gitignore-style patterns (see scanner2's ignoreMatcher) that are
+	// excluded from this library's scan, in addition to whatever .nmignore files are found while
+	// walking its tree.
+	ExcludePatterns []string
+}
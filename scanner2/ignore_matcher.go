@@ -0,0 +1,137 @@
+package scanner2
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/navidrome/navidrome/consts"
+	"github.com/navidrome/navidrome/log"
+)
+
+// nmIgnoreFile is the name of the per-directory file used to list scanner exclusion patterns,
+// following (a small subset of) .gitignore syntax.
+const nmIgnoreFile = ".nmignore"
+
+// ignoreRule is a single pattern loaded either from a library's ExcludePatterns config or from
+// a .nmignore file found while walking the tree.
+type ignoreRule struct {
+	baseDir  string // directory the pattern is relative to / inherited from
+	pattern  string
+	negate   bool // pattern was prefixed with "!"
+	dirOnly  bool // pattern had a trailing "/"
+	anchored bool // pattern contains a "/" other than a trailing one, so it's relative to baseDir
+}
+
+// ignoreMatcher accumulates ignoreRules down a directory tree. Every directory gets its own
+// matcher, built by extending its parent's with whatever the directory's own .nmignore adds, so
+// a rule defined higher up keeps applying to every descendant, and a nested .nmignore can add
+// more specific rules (or override an inherited one, since rules are evaluated in order and the
+// last match wins, same as .gitignore).
+type ignoreMatcher struct {
+	rules []ignoreRule
+}
+
+// newRootIgnoreMatcher seeds a matcher with a library's own ExcludePatterns, anchored at its root.
+func newRootIgnoreMatcher(root string, excludePatterns []string) *ignoreMatcher {
+	m := &ignoreMatcher{}
+	m.rules = appendPatterns(m.rules, root, excludePatterns)
+	return m
+}
+
+// child returns the matcher for a subdirectory: m itself when dir has no .nmignore patterns,
+// otherwise a new matcher inheriting all of m's rules plus whatever dir's own .nmignore file adds.
+// m itself is never modified, so sibling directories aren't affected by each other's ignore files.
+func (m *ignoreMatcher) child(dir string) *ignoreMatcher {
+	patterns, err := readIgnoreFile(filepath.Join(dir, nmIgnoreFile))
+	if err != nil {
+		log.Warn("Scanner: Error reading ignore file", "dir", dir, err)
+	}
+	if len(patterns) == 0 {
+		return m
+	}
+	child := &ignoreMatcher{rules: make([]ignoreRule, len(m.rules), len(m.rules)+len(patterns))}
+	copy(child.rules, m.rules)
+	child.rules = appendPatterns(child.rules, dir, patterns)
+	return child
+}
+
+// matches reports whether path (a direct child of the directory this matcher was built for, or
+// of one of its descendants) should be excluded from the scan.
+func (m *ignoreMatcher) matches(path string, isDir bool) bool {
+	ignored := false
+	for _, r := range m.rules {
+		if r.dirOnly && !isDir {
+			continue
+		}
+		rel, err := filepath.Rel(r.baseDir, path)
+		if err != nil || strings.HasPrefix(rel, "..") {
+			continue
+		}
+		target := rel
+		if !r.anchored {
+			target = filepath.Base(path)
+		}
+		if ok, _ := filepath.Match(r.pattern, target); ok {
+			ignored = !r.negate
+		}
+	}
+	return ignored
+}
+
+func appendPatterns(rules []ignoreRule, baseDir string, patterns []string) []ignoreRule {
+	for _, p := range patterns {
+		if rule, ok := parseIgnorePattern(baseDir, p); ok {
+			rules = append(rules, rule)
+		}
+	}
+	return rules
+}
+
+func parseIgnorePattern(baseDir, line string) (ignoreRule, bool) {
+	line = strings.TrimSpace(line)
+	if line == "" || strings.HasPrefix(line, "#") {
+		return ignoreRule{}, false
+	}
+	rule := ignoreRule{baseDir: baseDir}
+	if strings.HasPrefix(line, "!") {
+		rule.negate = true
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		rule.dirOnly = true
+		line = strings.TrimSuffix(line, "/")
+	}
+	// A pattern is anchored to baseDir if it has a "/" anywhere but a trailing one - including a
+	// leading "/" on its own, which must be checked before it's trimmed off below.
+	rule.anchored = strings.HasPrefix(line, "/") || strings.Contains(strings.TrimPrefix(line, "/"), "/")
+	line = strings.TrimPrefix(line, "/")
+	rule.pattern = line
+	return rule, true
+}
+
+func readIgnoreFile(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	var patterns []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		patterns = append(patterns, scanner.Text())
+	}
+	return patterns, scanner.Err()
+}
+
+// legacySkipScanFileIgnored preserves the scanner's original, simpler exclusion rule: a single
+// marker file (consts.SkipScanFile) dropped in a directory skips it entirely.
+func legacySkipScanFileIgnored(baseDir, name string) bool {
+	_, err := os.Stat(filepath.Join(baseDir, name, consts.SkipScanFile))
+	return err == nil
+}
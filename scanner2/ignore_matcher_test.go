@@ -0,0 +1,97 @@
+package scanner2
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/navidrome/navidrome/consts"
+)
+
+func TestIgnoreMatcher_RootPatterns(t *testing.T) {
+	root := t.TempDir()
+	m := newRootIgnoreMatcher(root, []string{"*.tmp", "Thumbs.db", "cache/"})
+
+	tests := []struct {
+		path  string
+		isDir bool
+		want  bool
+	}{
+		{filepath.Join(root, "song.tmp"), false, true},
+		{filepath.Join(root, "song.mp3"), false, false},
+		{filepath.Join(root, "Thumbs.db"), false, true},
+		{filepath.Join(root, "cache"), true, true},
+		{filepath.Join(root, "cache"), false, false}, // dirOnly pattern, not a dir here
+	}
+	for _, tt := range tests {
+		if got := m.matches(tt.path, tt.isDir); got != tt.want {
+			t.Errorf("matches(%q, isDir=%v) = %v, want %v", tt.path, tt.isDir, got, tt.want)
+		}
+	}
+}
+
+func TestIgnoreMatcher_ChildInheritsAndExtends(t *testing.T) {
+	root := t.TempDir()
+	sub := filepath.Join(root, "sub")
+	if err := os.Mkdir(sub, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(sub, nmIgnoreFile), []byte("*.bak\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	rootMatcher := newRootIgnoreMatcher(root, []string{"*.tmp"})
+	child := rootMatcher.child(sub)
+
+	if !child.matches(filepath.Join(sub, "a.tmp"), false) {
+		t.Error("child should still match patterns inherited from the root")
+	}
+	if !child.matches(filepath.Join(sub, "a.bak"), false) {
+		t.Error("child should match the pattern added by its own .nmignore")
+	}
+	if rootMatcher.matches(filepath.Join(root, "a.bak"), false) {
+		t.Error("root matcher must not be mutated by building a child")
+	}
+}
+
+func TestIgnoreMatcher_NegationOverridesEarlierMatch(t *testing.T) {
+	root := t.TempDir()
+	m := newRootIgnoreMatcher(root, []string{"*.mp3", "!keep.mp3"})
+
+	if m.matches(filepath.Join(root, "song.mp3"), false) == false {
+		t.Error("song.mp3 should be ignored by *.mp3")
+	}
+	if m.matches(filepath.Join(root, "keep.mp3"), false) {
+		t.Error("keep.mp3 should be un-ignored by the later !keep.mp3 rule")
+	}
+}
+
+func TestIgnoreMatcher_AnchoredPatternOnlyMatchesFromBase(t *testing.T) {
+	root := t.TempDir()
+	m := newRootIgnoreMatcher(root, []string{"/only-at-root.mp3"})
+
+	if !m.matches(filepath.Join(root, "only-at-root.mp3"), false) {
+		t.Error("anchored pattern should match at the base directory")
+	}
+	nested := filepath.Join(root, "nested", "only-at-root.mp3")
+	if m.matches(nested, false) {
+		t.Error("anchored pattern should not match outside its base directory")
+	}
+}
+
+func TestLegacySkipScanFileIgnored(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "album")
+	if err := os.Mkdir(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if legacySkipScanFileIgnored(root, "album") {
+		t.Error("directory without the marker file should not be skipped")
+	}
+	if err := os.WriteFile(filepath.Join(dir, consts.SkipScanFile), []byte{}, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if !legacySkipScanFileIgnored(root, "album") {
+		t.Error("directory with the marker file should be skipped")
+	}
+}
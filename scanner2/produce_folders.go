@@ -8,9 +8,11 @@ import (
 	"runtime"
 	"sort"
 	"strings"
+	"sync"
+	"sync/atomic"
 
 	"github.com/google/go-pipeline/pkg/pipeline"
-	"github.com/navidrome/navidrome/consts"
+	"github.com/navidrome/navidrome/conf"
 	"github.com/navidrome/navidrome/log"
 	"github.com/navidrome/navidrome/model"
 	"github.com/navidrome/navidrome/utils/pl"
@@ -31,29 +33,69 @@ func produceFolders(ctx context.Context, ds model.DataStore, libs []model.Librar
 		}
 	}()
 	return func(put func(entry *folderEntry)) error {
-		// TODO Parallelize multiple scanCtx
-		var total int64
-		for scanCtx := range pl.ReadOrDone(ctx, scanCtxChan) {
-			outputChan, err := walkDirTree(ctx, scanCtx)
-			if err != nil {
-				log.Warn(ctx, "Scanner: Error scanning library", "lib", scanCtx.lib.Name, err)
-			}
-			for folder := range pl.ReadOrDone(ctx, outputChan) {
-				put(folder)
-			}
-			total += scanCtx.numFolders.Load()
+		numWorkers := concurrentLibraries(len(libs))
+		var total atomic.Int64
+		var wg sync.WaitGroup
+		var putMutex sync.Mutex // `put` is not guaranteed to be safe for concurrent calls
+		wg.Add(numWorkers)
+		for i := 0; i < numWorkers; i++ {
+			go func() {
+				defer wg.Done()
+				for scanCtx := range pl.ReadOrDone(ctx, scanCtxChan) {
+					outputChan, err := walkDirTree(ctx, scanCtx)
+					if err != nil {
+						log.Warn(ctx, "Scanner: Error scanning library", "lib", scanCtx.lib.Name, err)
+					}
+					for folder := range pl.ReadOrDone(ctx, outputChan) {
+						putMutex.Lock()
+						put(folder)
+						putMutex.Unlock()
+					}
+					total.Add(scanCtx.numFolders.Load())
+				}
+			}()
+		}
+		wg.Wait()
+		log.Info(ctx, "Scanner: Finished loading all folders", "numFolders", total.Load())
+
+		if conf.Server.Scanner.WatcherEnabled {
+			return watchFolders(ctx, ds, libs)(func(entry *folderEntry) {
+				putMutex.Lock()
+				defer putMutex.Unlock()
+				put(entry)
+			})
 		}
-		log.Info(ctx, "Scanner: Finished loading all folders", "numFolders", total)
 		return nil
 	}
 }
 
+// concurrentLibraries returns how many libraries should be scanned in parallel, based on
+// conf.Server.Scanner.ConcurrentLibraries. A value <= 0 falls back to min(numLibs, NumCPU).
+func concurrentLibraries(numLibs int) int {
+	num := conf.Server.Scanner.ConcurrentLibraries
+	if num <= 0 {
+		num = numLibs
+		if cpus := runtime.NumCPU(); cpus < num {
+			num = cpus
+		}
+	}
+	if num < 1 {
+		num = 1
+	}
+	return num
+}
+
 func walkDirTree(ctx context.Context, scanCtx *scanContext) (<-chan *folderEntry, error) {
 	results := make(chan *folderEntry)
 	go func() {
 		defer close(results)
 		rootFolder := scanCtx.lib.Path
-		err := walkFolder(ctx, scanCtx, rootFolder, results)
+		matcher := newRootIgnoreMatcher(rootFolder, scanCtx.lib.ExcludePatterns)
+		var sem chan struct{}
+		if max := conf.Server.Scanner.ConcurrentFolders; max > 1 {
+			sem = make(chan struct{}, max)
+		}
+		err := walkFolder(ctx, scanCtx, rootFolder, results, matcher, sem)
 		if err != nil {
 			log.Error(ctx, "Scanner: There were errors reading directories from filesystem", "path", rootFolder, err)
 			return
@@ -63,57 +105,113 @@ func walkDirTree(ctx context.Context, scanCtx *scanContext) (<-chan *folderEntry
 	return results, nil
 }
 
-func walkFolder(ctx context.Context, scanCtx *scanContext, currentFolder string, results chan<- *folderEntry) error {
-	folder, children, err := loadDir(ctx, scanCtx, currentFolder)
+// walkFolder walks currentFolder and its descendants, emitting each folder only after all of its
+// descendants have been emitted (post-order), whether or not sem enables concurrent reads. With
+// sem set, sibling subtrees are walked at the same time, so their folders can interleave.
+//
+// sem, shared across the whole library walk, bounds how many directories are read (loadDir) at
+// once; it's nil when conf.Server.Scanner.ConcurrentFolders isn't set to something greater than
+// 1, in which case the walk stays fully serial. A goroutine only ever holds its slot around the
+// loadDir call and releases it before recursing into children, so a directory blocked waiting on
+// its children's completion never holds a slot one of those children needs - concurrency stays
+// bounded by cap(sem) regardless of tree depth, and parents can't deadlock waiting on children.
+func walkFolder(ctx context.Context, scanCtx *scanContext, currentFolder string, results chan<- *folderEntry, matcher *ignoreMatcher, sem chan struct{}) error {
+	if sem != nil {
+		sem <- struct{}{}
+	}
+	folder, children, childMatcher, err := loadDir(ctx, scanCtx, currentFolder, matcher)
+	if sem != nil {
+		<-sem
+	}
 	if err != nil {
 		log.Warn(ctx, "Scanner: Error loading dir. Skipping", "path", currentFolder, err)
 		return nil
 	}
 	scanCtx.numFolders.Add(1)
-	for _, c := range children {
-		err := walkFolder(ctx, scanCtx, c, results)
-		if err != nil {
-			return err
+
+	if sem == nil {
+		for _, c := range children {
+			if err := walkFolder(ctx, scanCtx, c, results, childMatcher, nil); err != nil {
+				return err
+			}
 		}
+	} else if err := walkChildrenConcurrently(ctx, scanCtx, children, results, childMatcher, sem); err != nil {
+		return err
+	}
+
+	emitFolder(ctx, scanCtx, folder, currentFolder, len(children), results)
+	return nil
+}
+
+// walkChildrenConcurrently walks every child of currentFolder at once, all sharing sem, and waits
+// for them (and everything below them) to finish before returning - so the caller can still emit
+// currentFolder strictly after its whole subtree, the same as the serial path does.
+func walkChildrenConcurrently(ctx context.Context, scanCtx *scanContext, children []string, results chan<- *folderEntry, matcher *ignoreMatcher, sem chan struct{}) error {
+	var wg sync.WaitGroup
+	var firstErr error
+	var errMutex sync.Mutex
+	wg.Add(len(children))
+	for _, c := range children {
+		c := c
+		go func() {
+			defer wg.Done()
+			if err := walkFolder(ctx, scanCtx, c, results, matcher, sem); err != nil {
+				errMutex.Lock()
+				if firstErr == nil {
+					firstErr = err
+				}
+				errMutex.Unlock()
+			}
+		}()
 	}
+	wg.Wait()
+	return firstErr
+}
 
+// emitFolder sends folder to results if it's new or has changed since the last scan, or if this
+// is a full rescan.
+func emitFolder(ctx context.Context, scanCtx *scanContext, folder *folderEntry, currentFolder string, numChildren int, results chan<- *folderEntry) {
 	if !folder.isOutdated() && !scanCtx.fullRescan {
-		return nil
+		return
 	}
 	dir := filepath.Clean(currentFolder)
 	log.Trace(ctx, "Scanner: Found directory", "_path", dir, "audioFiles", maps.Keys(folder.audioFiles),
 		"images", maps.Keys(folder.imageFiles), "playlists", folder.playlists, "imagesUpdatedAt", folder.imagesUpdatedAt,
-		"updTime", folder.updTime, "modTime", folder.modTime, "numChildren", len(children))
+		"updTime", folder.updTime, "modTime", folder.modTime, "numChildren", numChildren)
 	folder.path = dir
 	results <- folder
-
-	return nil
 }
 
-func loadDir(ctx context.Context, scanCtx *scanContext, dirPath string) (folder *folderEntry, children []string, err error) {
+// loadDir reads a single directory, classifying its entries and collecting the subdirectories to
+// recurse into. matcher is the accumulated ignore matcher from its parents; loadDir extends it
+// with dirPath's own .nmignore (if any) and returns the result so the caller can pass it down to
+// dirPath's children.
+func loadDir(ctx context.Context, scanCtx *scanContext, dirPath string, matcher *ignoreMatcher) (folder *folderEntry, children []string, childMatcher *ignoreMatcher, err error) {
 	folder = &folderEntry{scanCtx: scanCtx, path: dirPath}
 	folder.id = model.FolderID(scanCtx.lib, dirPath)
 	folder.updTime = scanCtx.getLastUpdatedInDB(folder.id)
 	folder.audioFiles = make(map[string]fs.DirEntry)
 	folder.imageFiles = make(map[string]fs.DirEntry)
 
+	childMatcher = matcher.child(dirPath)
+
 	dirInfo, err := os.Stat(dirPath)
 	if err != nil {
 		log.Warn(ctx, "Scanner: Error stating dir", "path", dirPath, err)
-		return nil, nil, err
+		return nil, nil, childMatcher, err
 	}
 	folder.modTime = dirInfo.ModTime()
 
 	dir, err := os.Open(dirPath)
 	if err != nil {
 		log.Warn(ctx, "Scanner: Error in Opening directory", "path", dirPath, err)
-		return folder, children, err
+		return folder, children, childMatcher, err
 	}
 	defer dir.Close()
 
 	for _, entry := range fullReadDir(ctx, dir) {
 		if ctx.Err() != nil {
-			return folder, children, ctx.Err()
+			return folder, children, childMatcher, ctx.Err()
 		}
 		isDir, err := isDirOrSymlinkToDir(dirPath, entry)
 		// Skip invalid symlinks
@@ -121,13 +219,19 @@ func loadDir(ctx context.Context, scanCtx *scanContext, dirPath string) (folder
 			log.Warn(ctx, "Scanner: Invalid symlink", "dir", filepath.Join(dirPath, entry.Name()), err)
 			continue
 		}
-		if isDir && !isDirIgnored(dirPath, entry) && isDirReadable(ctx, dirPath, entry) {
-			children = append(children, filepath.Join(dirPath, entry.Name()))
-		} else {
+		entryPath := filepath.Join(dirPath, entry.Name())
+		switch {
+		case isDir && !isDirIgnored(dirPath, entry, childMatcher) && isDirReadable(ctx, dirPath, entry):
+			children = append(children, entryPath)
+		case isDir:
+			// Ignored or unreadable directory, nothing more to do with it.
+		case childMatcher.matches(entryPath, false):
+			// Ignored file.
+		default:
 			fileInfo, err := entry.Info()
 			if err != nil {
 				log.Warn(ctx, "Scanner: Error getting fileInfo", "name", entry.Name(), err)
-				return folder, children, err
+				return folder, children, childMatcher, err
 			}
 			if fileInfo.ModTime().After(folder.modTime) {
 				folder.modTime = fileInfo.ModTime()
@@ -145,7 +249,7 @@ func loadDir(ctx context.Context, scanCtx *scanContext, dirPath string) (folder
 			}
 		}
 	}
-	return folder, children, nil
+	return folder, children, childMatcher, nil
 }
 
 // fullReadDir reads all files in the folder, skipping the ones with errors.
@@ -214,9 +318,9 @@ func isDirReadable(ctx context.Context, baseDir string, dirEnt fs.DirEntry) bool
 	return true
 }
 
-// isDirIgnored returns true if the directory represented by dirEnt contains an
-// `ignore` file (named after skipScanFile)
-func isDirIgnored(baseDir string, dirEnt fs.DirEntry) bool {
+// isDirIgnored returns true if the directory represented by dirEnt contains an `ignore` file
+// (named after skipScanFile), or matches one of matcher's accumulated exclusion patterns.
+func isDirIgnored(baseDir string, dirEnt fs.DirEntry, matcher *ignoreMatcher) bool {
 	// allows Album folders for albums which eg start with ellipses
 	name := dirEnt.Name()
 	if strings.HasPrefix(name, ".") && !strings.HasPrefix(name, "..") {
@@ -226,6 +330,8 @@ func isDirIgnored(baseDir string, dirEnt fs.DirEntry) bool {
 	if runtime.GOOS == "windows" && strings.EqualFold(name, "$RECYCLE.BIN") {
 		return true
 	}
-	_, err := os.Stat(filepath.Join(baseDir, name, consts.SkipScanFile))
-	return err == nil
+	if legacySkipScanFileIgnored(baseDir, name) {
+		return true
+	}
+	return matcher.matches(filepath.Join(baseDir, name), true)
 }
@@ -0,0 +1,230 @@
+package scanner2
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/google/go-pipeline/pkg/pipeline"
+	"github.com/navidrome/navidrome/conf"
+	"github.com/navidrome/navidrome/log"
+	"github.com/navidrome/navidrome/model"
+	"github.com/navidrome/navidrome/utils/pl"
+)
+
+// watchFolders returns a producer that, once produceFolders' own initial full walk of each library
+// is done, keeps observing each library root with fsnotify instead of going back to sleep until
+// the next scheduled scan. It does not walk the libraries itself. Only the directories that actually
+// changed are fed back into the pipeline as folderEntry values, so downstream stages (which
+// already know how to deal with a partial set of folders) don't need to change at all.
+//
+// It is only meant to be used when conf.Server.Scanner.WatcherEnabled is set; callers are
+// responsible for checking that before wiring it up.
+func watchFolders(ctx context.Context, ds model.DataStore, libs []model.Library) pipeline.ProducerFn[*folderEntry] {
+	return func(put func(entry *folderEntry)) error {
+		var wg sync.WaitGroup
+		wg.Add(len(libs))
+		for _, lib := range libs {
+			lib := lib
+			go func() {
+				defer wg.Done()
+				if err := watchLibrary(ctx, ds, lib, put); err != nil && !errors.Is(err, context.Canceled) {
+					log.Error(ctx, "Scanner: Error watching library", "lib", lib.Name, err)
+				}
+			}()
+		}
+		wg.Wait()
+		return nil
+	}
+}
+
+func watchLibrary(ctx context.Context, ds model.DataStore, lib model.Library, put func(entry *folderEntry)) error {
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+
+	if err := addRecursiveWatch(ctx, w, lib, lib.Path); err != nil {
+		return err
+	}
+	log.Info(ctx, "Scanner: Watching library for changes", "lib", lib.Name, "path", lib.Path)
+
+	debounce := newDirDebouncer(conf.Server.Scanner.WatcherDebounce)
+	fallback := time.NewTicker(conf.Server.Scanner.WatcherFallbackInterval)
+	defer fallback.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case event, ok := <-w.Events:
+			if !ok {
+				return nil
+			}
+			handleWatchEvent(ctx, w, lib, event, debounce, func(dir string) {
+				rescanDir(ctx, ds, lib, dir, put)
+			})
+		case err, ok := <-w.Errors:
+			if !ok {
+				return nil
+			}
+			if errors.Is(err, fsnotify.ErrEventOverflow) {
+				log.Warn(ctx, "Scanner: Watcher event queue overflowed, falling back to a full rescan", "lib", lib.Name)
+				rescanLibraryTree(ctx, ds, lib, put)
+				continue
+			}
+			log.Warn(ctx, "Scanner: Watcher error", "lib", lib.Name, err)
+		case <-fallback.C:
+			log.Debug(ctx, "Scanner: Running periodic full rescan", "lib", lib.Name)
+			rescanLibraryTree(ctx, ds, lib, put)
+		}
+	}
+}
+
+// handleWatchEvent keeps the watch tree up to date (adding watches for newly created
+// subdirectories) and debounces the affected directory before calling onSettled.
+func handleWatchEvent(ctx context.Context, w *fsnotify.Watcher, lib model.Library, event fsnotify.Event, debounce *dirDebouncer, onSettled func(dir string)) {
+	if event.Op&fsnotify.Create != 0 {
+		if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+			if err := addRecursiveWatch(ctx, w, lib, event.Name); err != nil {
+				log.Warn(ctx, "Scanner: Error watching new directory", "lib", lib.Name, "path", event.Name, err)
+			}
+		}
+	}
+	dir := changedDir(event)
+	if dir == "" {
+		return
+	}
+	debounce.trigger(dir, func() { onSettled(dir) })
+}
+
+// changedDir returns the directory a folderEntry should be rebuilt for in response to event. A
+// change to a directory itself (e.g. its creation) is reported as-is; a change to a file is
+// attributed to its parent, since that's the granularity folderEntry works at.
+func changedDir(event fsnotify.Event) string {
+	if event.Name == "" {
+		return ""
+	}
+	if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+		return event.Name
+	}
+	return filepath.Dir(event.Name)
+}
+
+// addRecursiveWatch adds a watch for root and every directory below it, honoring the same
+// .nmignore/ExcludePatterns rules the scanner itself uses so ignored subtrees aren't watched
+// (and don't trigger rescans) either. fsnotify has no cross-platform notion of a recursive
+// watch, so walking the tree ourselves is also the fallback used on platforms (Linux/inotify
+// included) that can only watch one directory at a time.
+func addRecursiveWatch(ctx context.Context, w *fsnotify.Watcher, lib model.Library, root string) error {
+	matchers := map[string]*ignoreMatcher{filepath.Dir(root): matcherForParent(lib, root)}
+	return filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			log.Warn(ctx, "Scanner: Error walking directory while setting up watches", "path", path, err)
+			return nil
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		parent := matchers[filepath.Dir(path)]
+		if path != root && isDirIgnored(filepath.Dir(path), d, parent) {
+			return fs.SkipDir
+		}
+		matchers[path] = parent.child(path)
+		if err := w.Add(path); err != nil {
+			log.Warn(ctx, "Scanner: Error watching directory", "path", path, err)
+		}
+		return nil
+	})
+}
+
+// matcherForParent rebuilds the ignore matcher that would apply to dir during a regular walk, by
+// replaying every .nmignore file between lib.Path and dir's parent. rescanDir only reloads one
+// directory at a time, so it can't just carry the matcher down from an in-progress walk like
+// walkFolder does.
+func matcherForParent(lib model.Library, dir string) *ignoreMatcher {
+	// Mirrors loadDir: the root's own .nmignore is read via m.child(lib.Path), the same way the
+	// first loadDir call of a regular walk extends the matcher built by newRootIgnoreMatcher.
+	m := newRootIgnoreMatcher(lib.Path, lib.ExcludePatterns).child(lib.Path)
+	rel, err := filepath.Rel(lib.Path, filepath.Dir(dir))
+	if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+		return m
+	}
+	current := lib.Path
+	for _, part := range strings.Split(rel, string(filepath.Separator)) {
+		current = filepath.Join(current, part)
+		m = m.child(current)
+	}
+	return m
+}
+
+// rescanDir reloads a single directory and feeds it back into the pipeline, without touching
+// the rest of the tree.
+func rescanDir(ctx context.Context, ds model.DataStore, lib model.Library, dir string, put func(entry *folderEntry)) {
+	scanCtx, err := newScannerContext(ctx, ds, lib, false)
+	if err != nil {
+		log.Error(ctx, "Scanner: Error creating scan context for watcher rescan", "lib", lib.Name, err)
+		return
+	}
+	matcher := matcherForParent(lib, dir)
+	folder, _, _, err := loadDir(ctx, scanCtx, dir, matcher)
+	if err != nil {
+		log.Warn(ctx, "Scanner: Error reloading watched directory", "path", dir, err)
+		return
+	}
+	folder.path = filepath.Clean(dir)
+	put(folder)
+}
+
+// rescanLibraryTree walks the whole library again, the same way the initial scan does. Used as
+// a fallback when the watcher can't be trusted to have seen every change (e.g. an overflowed
+// event queue, or the periodic safety-net rescan).
+func rescanLibraryTree(ctx context.Context, ds model.DataStore, lib model.Library, put func(entry *folderEntry)) {
+	scanCtx, err := newScannerContext(ctx, ds, lib, false)
+	if err != nil {
+		log.Error(ctx, "Scanner: Error creating scan context for watcher full rescan", "lib", lib.Name, err)
+		return
+	}
+	outputChan, err := walkDirTree(ctx, scanCtx)
+	if err != nil {
+		log.Warn(ctx, "Scanner: Error during watcher full rescan", "lib", lib.Name, err)
+		return
+	}
+	for folder := range pl.ReadOrDone(ctx, outputChan) {
+		put(folder)
+	}
+}
+
+// dirDebouncer coalesces bursts of events for the same directory into a single call, firing fn
+// only after the directory has been quiet for the configured window.
+type dirDebouncer struct {
+	window time.Duration
+
+	mu     sync.Mutex
+	timers map[string]*time.Timer
+}
+
+func newDirDebouncer(window time.Duration) *dirDebouncer {
+	return &dirDebouncer{window: window, timers: make(map[string]*time.Timer)}
+}
+
+func (d *dirDebouncer) trigger(dir string, fn func()) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if t, ok := d.timers[dir]; ok {
+		t.Stop()
+	}
+	d.timers[dir] = time.AfterFunc(d.window, func() {
+		d.mu.Lock()
+		delete(d.timers, dir)
+		d.mu.Unlock()
+		fn()
+	})
+}
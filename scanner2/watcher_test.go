@@ -0,0 +1,112 @@
+package scanner2
+
+import (
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/navidrome/navidrome/model"
+)
+
+func TestChangedDir_FileEvent(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "track.mp3")
+	if err := os.WriteFile(file, []byte{}, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	got := changedDir(fsnotify.Event{Name: file, Op: fsnotify.Write})
+	if got != dir {
+		t.Errorf("changedDir(file) = %q, want its parent %q", got, dir)
+	}
+}
+
+func TestChangedDir_DirEvent(t *testing.T) {
+	dir := t.TempDir()
+	sub := filepath.Join(dir, "sub")
+	if err := os.Mkdir(sub, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	got := changedDir(fsnotify.Event{Name: sub, Op: fsnotify.Create})
+	if got != sub {
+		t.Errorf("changedDir(dir) = %q, want the directory itself %q", got, sub)
+	}
+}
+
+func TestChangedDir_EmptyEvent(t *testing.T) {
+	if got := changedDir(fsnotify.Event{}); got != "" {
+		t.Errorf("changedDir(empty event) = %q, want empty string", got)
+	}
+}
+
+func TestChangedDir_DeletedPathFallsBackToParent(t *testing.T) {
+	dir := t.TempDir()
+	gone := filepath.Join(dir, "deleted.mp3")
+	got := changedDir(fsnotify.Event{Name: gone, Op: fsnotify.Remove})
+	if got != dir {
+		t.Errorf("changedDir(deleted file) = %q, want its parent %q", got, dir)
+	}
+}
+
+func TestDirDebouncer_CoalescesBurstIntoOneCall(t *testing.T) {
+	d := newDirDebouncer(20 * time.Millisecond)
+	var calls atomic.Int64
+	for i := 0; i < 5; i++ {
+		d.trigger("/some/dir", func() { calls.Add(1) })
+	}
+	time.Sleep(60 * time.Millisecond)
+	if got := calls.Load(); got != 1 {
+		t.Errorf("calls = %d, want exactly 1 after a burst of triggers", got)
+	}
+}
+
+func TestDirDebouncer_SeparateDirsFireIndependently(t *testing.T) {
+	d := newDirDebouncer(10 * time.Millisecond)
+	var a, b atomic.Int64
+	d.trigger("/dir/a", func() { a.Add(1) })
+	d.trigger("/dir/b", func() { b.Add(1) })
+	time.Sleep(40 * time.Millisecond)
+	if a.Load() != 1 || b.Load() != 1 {
+		t.Errorf("a=%d b=%d, want both to have fired once", a.Load(), b.Load())
+	}
+}
+
+func TestMatcherForParent_ReplaysNmignoreChain(t *testing.T) {
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, nmIgnoreFile), []byte("*.tmp\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	sub := filepath.Join(root, "artist", "album")
+	if err := os.MkdirAll(sub, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(filepath.Dir(sub), nmIgnoreFile), []byte("*.bak\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	lib := model.Library{Path: root, ExcludePatterns: []string{"*.log"}}
+
+	m := matcherForParent(lib, filepath.Join(sub, "track.mp3"))
+
+	if !m.matches(filepath.Join(sub, "x.log"), false) {
+		t.Error("matcher should still carry the library's own ExcludePatterns")
+	}
+	if !m.matches(filepath.Join(sub, "x.tmp"), false) {
+		t.Error("matcher should carry the root .nmignore's rules")
+	}
+	if !m.matches(filepath.Join(sub, "x.bak"), false) {
+		t.Error("matcher should carry the intermediate directory's .nmignore rules")
+	}
+}
+
+func TestMatcherForParent_RootDirHasNoParentToReplay(t *testing.T) {
+	root := t.TempDir()
+	lib := model.Library{Path: root, ExcludePatterns: []string{"*.log"}}
+
+	m := matcherForParent(lib, filepath.Join(root, "track.mp3"))
+
+	if !m.matches(filepath.Join(root, "x.log"), false) {
+		t.Error("matcher for a file directly under the library root should still apply ExcludePatterns")
+	}
+}
@@ -0,0 +1,128 @@
+package subsonic
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/navidrome/navidrome/core"
+	"github.com/navidrome/navidrome/log"
+)
+
+// Router wires the Subsonic API's download endpoint to an Archiver. It's kept minimal here since
+// this package otherwise only deals with streaming a single archive response back to the client.
+//
+// Router implements http.Handler itself (via its internal mux) so it can be mounted directly,
+// e.g. `http.Handle("/rest/", http.StripPrefix("/rest", subsonic.NewRouter(archiver)))`. Its mux
+// only matches the paths /download and /download.view, so any mount prefix must be stripped.
+type Router struct {
+	archiver core.Archiver
+	mux      *http.ServeMux
+}
+
+func NewRouter(archiver core.Archiver) *Router {
+	r := &Router{archiver: archiver, mux: http.NewServeMux()}
+	r.mux.HandleFunc("/download", r.Download)
+	r.mux.HandleFunc("/download.view", r.Download)
+	return r
+}
+
+func (r *Router) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.mux.ServeHTTP(w, req)
+}
+
+// validArchiveFormats are the archiveFormat values newArchiveWriter accepts; kept in sync with it
+// so Download can reject a bad format before writing anything to the response.
+var validArchiveFormats = map[core.ArchiveFormat]bool{
+	"":                      true,
+	core.ArchiveFormatZip:   true,
+	core.ArchiveFormatTar:   true,
+	core.ArchiveFormatTarGz: true,
+}
+
+// Download implements the Subsonic `download` endpoint. In addition to the standard `id`
+// parameter, it accepts:
+//   - format/maxBitRate: the same transcoding parameters other streaming endpoints use
+//   - archiveFormat: "zip" (the default), "tar" or "tar.gz" - see core.ArchiveFormat
+//   - includePlaylist: when "true", embeds an auto-generated M3U8 alongside an album/artist's
+//     tracks, preserving their order once extracted
+//
+// The `id` can refer to an album, artist or playlist; which one it is determines which of the
+// Archiver's methods is used, the same way the rest of the Subsonic API resolves a generic id.
+func (r *Router) Download(w http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+	id := req.URL.Query().Get("id")
+	if id == "" {
+		http.Error(w, "id parameter is required", http.StatusBadRequest)
+		return
+	}
+	archiveFormat := core.ArchiveFormat(req.URL.Query().Get("archiveFormat"))
+	if !validArchiveFormats[archiveFormat] {
+		http.Error(w, fmt.Sprintf("unsupported archiveFormat: %s", archiveFormat), http.StatusBadRequest)
+		return
+	}
+	format := req.URL.Query().Get("format")
+	bitrate := intParam(req, "maxBitRate", 0)
+	includePlaylist := req.URL.Query().Get("includePlaylist") == "true"
+
+	w.Header().Set("Content-Type", contentTypeForArchive(archiveFormat))
+
+	var err error
+	switch idTypeOf(id) {
+	case idTypeArtist:
+		err = r.archiver.ZipArtist(ctx, id, archiveFormat, format, bitrate, includePlaylist, w)
+	case idTypePlaylist:
+		err = r.archiver.ZipPlaylist(ctx, id, archiveFormat, format, bitrate, w)
+	default:
+		err = r.archiver.ZipAlbum(ctx, id, archiveFormat, format, bitrate, includePlaylist, w)
+	}
+	if err != nil {
+		log.Error(ctx, "Error downloading archive", "id", id, "archiveFormat", archiveFormat, err)
+	}
+}
+
+func contentTypeForArchive(format core.ArchiveFormat) string {
+	switch format {
+	case core.ArchiveFormatTar:
+		return "application/x-tar"
+	case core.ArchiveFormatTarGz:
+		return "application/gzip"
+	default:
+		return "application/zip"
+	}
+}
+
+type idType int
+
+const (
+	idTypeAlbum idType = iota
+	idTypeArtist
+	idTypePlaylist
+)
+
+// idTypeOf mirrors the prefix convention the rest of the Subsonic API already uses to tell apart
+// the different kinds of ids a generic `id` parameter can carry.
+func idTypeOf(id string) idType {
+	switch {
+	case len(id) > 2 && id[:2] == "ar":
+		return idTypeArtist
+	case len(id) > 2 && id[:2] == "pl":
+		return idTypePlaylist
+	default:
+		return idTypeAlbum
+	}
+}
+
+func intParam(req *http.Request, name string, def int) int {
+	v := req.URL.Query().Get(name)
+	if v == "" {
+		return def
+	}
+	n := 0
+	for _, c := range v {
+		if c < '0' || c > '9' {
+			return def
+		}
+		n = n*10 + int(c-'0')
+	}
+	return n
+}
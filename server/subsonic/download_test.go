@@ -0,0 +1,135 @@
+package subsonic
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/navidrome/navidrome/core"
+)
+
+// fakeArchiver records the arguments each method was called with, so tests can assert that
+// request parameters actually reached the Archiver instead of just being parsed and discarded.
+type fakeArchiver struct {
+	gotArchiveFormat   core.ArchiveFormat
+	gotIncludePlaylist bool
+	calledMethod       string
+}
+
+func (f *fakeArchiver) ZipAlbum(_ context.Context, _ string, archiveFormat core.ArchiveFormat, _ string, _ int, includePlaylist bool, w io.Writer) error {
+	f.calledMethod = "album"
+	f.gotArchiveFormat = archiveFormat
+	f.gotIncludePlaylist = includePlaylist
+	_, err := w.Write([]byte("fake-album-archive"))
+	return err
+}
+
+func (f *fakeArchiver) ZipArtist(_ context.Context, _ string, archiveFormat core.ArchiveFormat, _ string, _ int, includePlaylist bool, w io.Writer) error {
+	f.calledMethod = "artist"
+	f.gotArchiveFormat = archiveFormat
+	f.gotIncludePlaylist = includePlaylist
+	_, err := w.Write([]byte("fake-artist-archive"))
+	return err
+}
+
+func (f *fakeArchiver) ZipPlaylist(_ context.Context, _ string, archiveFormat core.ArchiveFormat, _ string, _ int, w io.Writer) error {
+	f.calledMethod = "playlist"
+	f.gotArchiveFormat = archiveFormat
+	_, err := w.Write([]byte("fake-playlist-archive"))
+	return err
+}
+
+func TestRouter_Download_AlbumWithIncludePlaylistAndTarFormat(t *testing.T) {
+	archiver := &fakeArchiver{}
+	r := NewRouter(archiver)
+
+	req := httptest.NewRequest(http.MethodGet, "/download?id=al-1&archiveFormat=tar&includePlaylist=true", nil)
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200, body = %q", rec.Code, rec.Body.String())
+	}
+	if archiver.calledMethod != "album" {
+		t.Errorf("called method = %q, want album", archiver.calledMethod)
+	}
+	if archiver.gotArchiveFormat != core.ArchiveFormatTar {
+		t.Errorf("archiveFormat = %q, want tar", archiver.gotArchiveFormat)
+	}
+	if !archiver.gotIncludePlaylist {
+		t.Error("includePlaylist should have reached the Archiver as true")
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "application/x-tar" {
+		t.Errorf("Content-Type = %q, want application/x-tar", ct)
+	}
+}
+
+func TestRouter_Download_ArtistRoutesByIdPrefix(t *testing.T) {
+	archiver := &fakeArchiver{}
+	r := NewRouter(archiver)
+
+	req := httptest.NewRequest(http.MethodGet, "/download.view?id=ar-1", nil)
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+
+	if archiver.calledMethod != "artist" {
+		t.Errorf("called method = %q, want artist", archiver.calledMethod)
+	}
+}
+
+func TestRouter_Download_ArtistWithIncludePlaylist(t *testing.T) {
+	archiver := &fakeArchiver{}
+	r := NewRouter(archiver)
+
+	req := httptest.NewRequest(http.MethodGet, "/download?id=ar-1&includePlaylist=true", nil)
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+
+	if archiver.calledMethod != "artist" {
+		t.Fatalf("called method = %q, want artist", archiver.calledMethod)
+	}
+	if !archiver.gotIncludePlaylist {
+		t.Error("includePlaylist=true on an artist download should reach ZipArtist")
+	}
+}
+
+func TestRouter_Download_PlaylistIgnoresIncludePlaylist(t *testing.T) {
+	archiver := &fakeArchiver{}
+	r := NewRouter(archiver)
+
+	req := httptest.NewRequest(http.MethodGet, "/download?id=pl-1&includePlaylist=true", nil)
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+
+	if archiver.calledMethod != "playlist" {
+		t.Errorf("called method = %q, want playlist", archiver.calledMethod)
+	}
+}
+
+func TestRouter_Download_MissingId(t *testing.T) {
+	r := NewRouter(&fakeArchiver{})
+	req := httptest.NewRequest(http.MethodGet, "/download", nil)
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want 400", rec.Code)
+	}
+}
+
+func TestRouter_Download_UnsupportedArchiveFormatReturns400WithoutCallingArchiver(t *testing.T) {
+	archiver := &fakeArchiver{}
+	r := NewRouter(archiver)
+	req := httptest.NewRequest(http.MethodGet, "/download?id=al-1&archiveFormat=rar", nil)
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want 400", rec.Code)
+	}
+	if archiver.calledMethod != "" {
+		t.Error("archiver should not have been called for an unsupported archiveFormat")
+	}
+}